@@ -0,0 +1,136 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filehandling ties together parsing (pegomock/mockgen), code
+// generation, and writing the result(s) to disk for the "generate" command.
+package filehandling
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/petergtz/pegomock/pegomock/mockgen"
+	"github.com/petergtz/pegomock/pegomock/mockgen/model"
+	"github.com/petergtz/pegomock/pegomock/util"
+)
+
+// GenerateMockFileInOutputDir parses sourceArgs (a package path plus
+// interface names, or a single .go file) using the given parser backend,
+// generates a mock, and writes it plus any requested matchers to disk.
+// sourceRoot, if non-empty, is stripped from the package's absolute source
+// path, whether parsed or taken from fromModel, so the generated file
+// doesn't embed the absolute
+// filesystem layout of the machine it was built on (see --source-root).
+// fromModel, if non-nil, is used as-is instead of parsing sourceArgs,
+// letting callers reuse a model.Package produced by the "model" command
+// (via --from-model) instead of paying for a parse on every invocation.
+// Errors are returned rather than exiting the process, so long-running
+// callers such as watch/Daemon can log a failure for one interface and
+// keep running instead of taking down the whole process.
+func GenerateMockFileInOutputDir(
+	sourceArgs []string,
+	destinationDir string,
+	destination string,
+	packageOut string,
+	selfPackage string,
+	debugParser bool,
+	out io.Writer,
+	backend mockgen.Backend,
+	shouldGenerateMatchers bool,
+	matchersDestination string,
+	sourceRoot string,
+	fromModel *model.Package,
+) error {
+	pkg := fromModel
+	if pkg == nil {
+		parser, err := mockgen.NewParser(backend)
+		if err != nil {
+			return err
+		}
+
+		pkg, err = parsePackage(parser, sourceArgs)
+		if err != nil {
+			return err
+		}
+	}
+	pkg.SourcePath = util.RelativeSourcePath(sourceRoot, pkg.SourcePath)
+
+	if debugParser {
+		fmt.Fprintf(out, "%+v\n", pkg)
+	}
+
+	if err := os.MkdirAll(destinationDir, 0755); err != nil {
+		return err
+	}
+
+	generated, err := mockgen.GenerateMockFileContent(pkg, packageOut, selfPackage)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(destination, generated, 0644); err != nil {
+		return err
+	}
+
+	if shouldGenerateMatchers {
+		realMatchersDestination := matchersDestination
+		if realMatchersDestination == "" {
+			realMatchersDestination = filepath.Join(destinationDir, "matchers")
+		}
+		if err := mockgen.GenerateMatcherFiles(pkg, realMatchersDestination); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintModel parses sourceArgs the same way GenerateMockFileInOutputDir
+// does, and writes the resulting model.Package to stdout as indented JSON;
+// errors go to out instead, so redirecting stdout (e.g. pegomock model . Foo
+// > model.json) captures only the model. It's the backend for the "model"
+// command: build systems can cache this output and feed it back into
+// generate's --from-model to skip parsing, and it's hand-editable for cases
+// the parser gets wrong.
+func PrintModel(sourceArgs []string, stdout io.Writer, out io.Writer, backend mockgen.Backend, sourceRoot string) {
+	parser, err := mockgen.NewParser(backend)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		os.Exit(1)
+	}
+
+	pkg, err := parsePackage(parser, sourceArgs)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		os.Exit(1)
+	}
+	pkg.SourcePath = util.RelativeSourcePath(sourceRoot, pkg.SourcePath)
+	pkg.Imports = model.ComputeImports(pkg)
+
+	encoded, err := json.MarshalIndent(pkg, "", "  ")
+	if err != nil {
+		fmt.Fprintln(out, err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(stdout, string(encoded))
+}
+
+func parsePackage(parser mockgen.Parser, sourceArgs []string) (*model.Package, error) {
+	if util.SourceMode(sourceArgs) {
+		return parser.ParseFile(sourceArgs[0], nil)
+	}
+	return parser.ParseInterfaces(sourceArgs[0], sourceArgs[1:])
+}
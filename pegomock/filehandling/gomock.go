@@ -0,0 +1,137 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filehandling
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/petergtz/pegomock/pegomock/mockgen"
+	"github.com/petergtz/pegomock/pegomock/mockgen/model"
+	"github.com/petergtz/pegomock/pegomock/util"
+)
+
+// GomockOptions mirrors the flags golang/mock's mockgen accepts in source
+// mode, so pegomock can act as a drop-in mockgen_tool for build rules such
+// as rules_go's gomock().
+type GomockOptions struct {
+	Source        string
+	Interfaces    []string // empty means every interface in Source's package, including those declared in its other files
+	Destination   string   // empty means stdout
+	PackageOut    string
+	SelfPackage   string
+	AuxFiles      map[string]string // pkg -> path.go
+	MockNames     map[string]string // interface -> generated mock type name
+	CopyrightFile string
+	Imports       map[string]string // name -> import path
+	// SourceRoot, if non-empty, is stripped from Source in the generated
+	// file's header, so the output doesn't embed the absolute filesystem
+	// layout of the machine it was built on (see --source-root).
+	SourceRoot string
+}
+
+// GenerateGomockCompatibleMockFile generates a mock for opts.Source the way
+// mockgen would, so that output is interoperable with tooling that expects
+// mockgen's file layout and flag semantics. When opts.Destination is empty,
+// the generated mock is written to stdout, mockgen's documented stdout mode
+// that Bazel's gomock()/mockgen_tool rules rely on to capture it; errors go
+// to out instead.
+func GenerateGomockCompatibleMockFile(opts GomockOptions, stdout io.Writer, out io.Writer) {
+	parser := mockgen.NewPackagesParser()
+
+	pkg, err := parser.ParseFile(opts.Source, opts.Interfaces)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		os.Exit(1)
+	}
+	pkg.SourcePath = util.RelativeSourcePath(opts.SourceRoot, pkg.SourcePath)
+
+	for _, auxFile := range sortedValues(opts.AuxFiles) {
+		if _, err := os.Stat(auxFile); err != nil {
+			fmt.Fprintln(out, fmt.Errorf("--aux_files: %w", err))
+			os.Exit(1)
+		}
+	}
+
+	renameInterfaces(pkg, opts.MockNames)
+
+	packageOut := opts.PackageOut
+	if packageOut == "" {
+		packageOut = pkg.Name + "_mocks"
+	}
+
+	generated, err := mockgen.GenerateMockFileContent(pkg, packageOut, opts.SelfPackage, mockgen.ImportAliases(opts.Imports))
+	if err != nil {
+		fmt.Fprintln(out, err)
+		os.Exit(1)
+	}
+
+	if opts.CopyrightFile != "" {
+		header, err := os.ReadFile(opts.CopyrightFile)
+		if err != nil {
+			fmt.Fprintln(out, fmt.Errorf("--copyright_file: %w", err))
+			os.Exit(1)
+		}
+		generated = append(commentOut(header), generated...)
+	}
+
+	if opts.Destination == "" {
+		fmt.Fprint(stdout, string(generated))
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Destination), 0755); err != nil {
+		fmt.Fprintln(out, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(opts.Destination, generated, 0644); err != nil {
+		fmt.Fprintln(out, err)
+		os.Exit(1)
+	}
+}
+
+// renameInterfaces applies --mock_names by setting MockName, which the
+// template uses verbatim as the generated mock struct's name instead of
+// deriving it from Name.
+func renameInterfaces(pkg *model.Package, names map[string]string) {
+	for _, iface := range pkg.Interfaces {
+		if name, ok := names[iface.Name]; ok {
+			iface.MockName = name
+		}
+	}
+}
+
+// sortedValues returns m's values in ascending order, so callers that only
+// care about presence (not which key matched) iterate deterministically
+// regardless of Go's randomized map order.
+func sortedValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func commentOut(header []byte) []byte {
+	lines := strings.Split(strings.TrimRight(string(header), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "// " + line
+	}
+	return []byte(strings.Join(lines, "\n") + "\n\n")
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filehandling
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/petergtz/pegomock/pegomock/mockgen"
+	"github.com/petergtz/pegomock/pegomock/mockgen/model"
+	"github.com/petergtz/pegomock/pegomock/util"
+)
+
+// chdir switches the process working directory to dir and restores it when
+// the test finishes, so tests can prove generation doesn't depend on cwd.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+// packageFixture references types from several different external packages
+// across several methods, so ComputeImportsExcluding actually has more than
+// one entry to sort -- a fixture with zero or one external type would pass
+// this test even if map iteration order leaked into the generated imports.
+func packageFixture(sourceRoot, absSourcePath string) *model.Package {
+	return &model.Package{
+		Name:       "foo",
+		PkgPath:    "example.com/foo",
+		SourcePath: util.RelativeSourcePath(sourceRoot, absSourcePath),
+		Interfaces: []*model.Interface{{
+			Name: "Foo",
+			Methods: []*model.Method{
+				{Name: "Bar", In: []*model.Param{{Name: "x", Type: model.PredeclaredType("int")}}},
+				{Name: "Baz", Out: []*model.Param{{Type: &model.NamedType{Package: "time", PkgPath: "time", Type: "Time"}}}},
+				{Name: "Qux", In: []*model.Param{{Name: "w", Type: &model.NamedType{Package: "io", PkgPath: "io", Type: "Writer"}}},
+					Out: []*model.Param{{Type: &model.NamedType{Package: "errors", PkgPath: "errors", Type: "Error"}}}},
+				{Name: "Quux", In: []*model.Param{{Name: "ctx", Type: &model.NamedType{Package: "context", PkgPath: "context", Type: "Context"}}}},
+			},
+		}},
+	}
+}
+
+// TestGenerationIsReproducibleAcrossWorkingDirectories guards against
+// regressions where GenerateMockFileContent starts depending on the
+// process's working directory, which would break hermetic
+// builds (Bazel's gomock rule, Nix, remote caches).
+func TestGenerationIsReproducibleAcrossWorkingDirectories(t *testing.T) {
+	sourceRoot := t.TempDir()
+	absSourcePath := filepath.Join(sourceRoot, "pkg", "foo.go")
+
+	dirA, dirB := t.TempDir(), t.TempDir()
+
+	chdir(t, dirA)
+	first, err := mockgen.GenerateMockFileContent(packageFixture(sourceRoot, absSourcePath), "foo_test", "")
+	if err != nil {
+		t.Fatalf("generating from %s: %v", dirA, err)
+	}
+
+	chdir(t, dirB)
+	second, err := mockgen.GenerateMockFileContent(packageFixture(sourceRoot, absSourcePath), "foo_test", "")
+	if err != nil {
+		t.Fatalf("generating from %s: %v", dirB, err)
+	}
+
+	if !bytes.Equal(first, second) {
+		t.Fatalf("generated output differs depending on the working directory it was generated from:\n--- %s ---\n%s\n--- %s ---\n%s",
+			dirA, first, dirB, second)
+	}
+	if bytes.Contains(first, []byte(sourceRoot)) {
+		t.Fatalf("generated output embeds the absolute --source-root %q:\n%s", sourceRoot, first)
+	}
+}
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
@@ -24,6 +25,8 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/petergtz/pegomock/pegomock/filehandling"
+	"github.com/petergtz/pegomock/pegomock/mockgen"
+	"github.com/petergtz/pegomock/pegomock/mockgen/model"
 	"github.com/petergtz/pegomock/pegomock/remove"
 	"github.com/petergtz/pegomock/pegomock/util"
 	"github.com/petergtz/pegomock/pegomock/watch"
@@ -34,10 +37,15 @@ var (
 )
 
 func main() {
-	Run(os.Args, os.Stderr, os.Stdin, app, make(chan bool))
+	Run(os.Args, os.Stdout, os.Stderr, os.Stdin, app, make(chan bool))
 }
 
-func Run(cliArgs []string, out io.Writer, in io.Reader, app *kingpin.Application, done chan bool) {
+// Run parses cliArgs and executes the requested command. out receives usage
+// text, errors, and --debug dumps; stdout receives the commands' actual
+// generated content (the "model" command's JSON, and "gomock"'s mock source
+// when --destination is omitted), so shell redirection and Bazel's
+// mockgen_tool-style piping see only that content on stdout.
+func Run(cliArgs []string, stdout io.Writer, out io.Writer, in io.Reader, app *kingpin.Application, done chan bool) {
 
 	workingDir, err := os.Getwd()
 	app.FatalIfError(err, "")
@@ -52,20 +60,54 @@ func Run(cliArgs []string, out io.Writer, in io.Reader, app *kingpin.Application
 		//       So for now it's not tested.
 		selfPackage            = generateCmd.Flag("self_package", "If set, the package this mock will be part of.").String()
 		debugParser            = generateCmd.Flag("debug", "Print debug information.").Short('d').Bool()
-		shouldGenerateMatchers = generateCmd.Flag("generate-matchers", "Generate matchers for all non built-in types in a \"matchers\" "+
-			"directory in the same directory where the mock file gets generated.").Short('m').Default("false").Bool()
+		shouldGenerateMatchers = generateCmd.Flag("generate-matchers", "For generic interfaces, generate the shared AnyT[T] matcher in a "+
+			"\"matchers\" directory in the same directory where the mock file gets generated; a no-op otherwise.").Short('m').Default("false").Bool()
 		matchersDestination = generateCmd.Flag("matchers-dir", "Generate matchers in the specified directory; defaults to "+
 			filepath.Join("<mockdir>", "matchers")).Short('p').String()
-		useExperimentalModelGen = generateCmd.Flag("use-experimental-model-gen", "pegomock includes a new experimental source parser based on "+
-			"golang.org/x/tools/go/loader. It's currently experimental, but should be more powerful "+
-			"than the current reflect-based modelgen. E.g. reflect cannot detect method parameter names,"+
-			" and has to generate them based on a pattern. In a code editor with code assistence, this doesn't provide good help. "+
-			"\n\nThis option only works when specifying package path + interface, not with .go source files. Also, you can only specify *one* interface. This option cannot be used with the watch command.").Bool()
-		generateCmdArgs = generateCmd.Arg("args", "A (optional) Go package path + space-separated interface or a .go file").Required().Strings()
+		sourceRoot = generateCmd.Flag("source-root", "If set, strip this prefix from any absolute source path recorded in the generated "+
+			"output, so it's reproducible across working directories (e.g. in a Bazel sandbox).").String()
+		parser = generateCmd.Flag("parser", "Which source parser to use: "+
+			"\"packages\" (default) is built on golang.org/x/tools/go/packages, understands Go modules, build tags and CGO, "+
+			"and can parse multiple interfaces per invocation including from .go source files. "+
+			"\"reflect\" (the original backend) and \"loader\" (the former --use-experimental-model-gen backend, deprecated) are still accepted, "+
+			"but are not implemented in this build and fail with an error.").
+			Default(string(mockgen.BackendPackages)).
+			Enum(string(mockgen.BackendPackages), string(mockgen.BackendReflect), string(mockgen.BackendLoader))
+		fromModel = generateCmd.Flag("from-model", "Read a model.Package as JSON from this file, as produced by the \"model\" command, "+
+			"instead of parsing args. Bypasses --parser entirely; args may be omitted.").String()
+		generateCmdArgs = generateCmd.Arg("args", "A Go package path + space-separated interface or a .go file; "+
+			"may be omitted when --from-model is set").Strings()
+
+		modelCmd = app.Command("model", "Parse interfaces and print the resulting model as JSON, instead of generating a mock. "+
+			"The output is a model.Package, and can be fed back into generate via --from-model.")
+		modelSourceRoot = modelCmd.Flag("source-root", "If set, strip this prefix from any absolute source path recorded in the "+
+			"output, so it's reproducible across working directories (e.g. in a Bazel sandbox).").String()
+		modelParser = modelCmd.Flag("parser", "Which source parser to use; see generate --parser.").
+				Default(string(mockgen.BackendPackages)).
+				Enum(string(mockgen.BackendPackages), string(mockgen.BackendReflect), string(mockgen.BackendLoader))
+		modelCmdArgs = modelCmd.Arg("args", "A Go package path + space-separated interface or a .go file").Required().Strings()
 
 		watchCmd       = app.Command("watch", "Watch over changes in interfaces and regenerate mocks if changes are detected.")
 		watchRecursive = watchCmd.Flag("recursive", "Recursively watch sub-directories as well.").Short('r').Bool()
-		watchPackages  = watchCmd.Arg("directories...", "One or more directories of Go packages to watch").Strings()
+		watchPoll      = watchCmd.Flag("poll", "Poll for changes every 2 seconds instead of using fsnotify. Useful on filesystems "+
+			"where fsnotify is unreliable, such as network mounts or WSL.").Bool()
+		watchDaemon = watchCmd.Flag("daemon", "While watching, also accept 'regenerate', 'list', and 'status' commands over a Unix socket "+
+			"(see --socket). Ignored with --poll.").Bool()
+		watchSocket   = watchCmd.Flag("socket", "Unix socket path to accept commands on when --daemon is set; defaults to pegomock.sock in the current directory.").String()
+		watchPackages = watchCmd.Arg("directories...", "One or more directories of Go packages to watch").Strings()
+
+		gomockCmd         = app.Command("gomock", "Generate a mock in a form compatible with golang/mock's mockgen, for use as a drop-in mockgen_tool.")
+		gomockSource      = gomockCmd.Flag("source", "A .go source file containing the interface(s) to mock.").Required().String()
+		gomockInterfaces  = gomockCmd.Flag("interfaces", "Comma-separated names of interfaces to mock; defaults to every interface in the package --source belongs to, including those declared in its other files.").String()
+		gomockDestination = gomockCmd.Flag("destination", "Output file; defaults to stdout.").String()
+		gomockPackageOut  = gomockCmd.Flag("package", "Package of the generated code; defaults to the source package's name suffixed with _mocks.").String()
+		gomockSelfPackage = gomockCmd.Flag("self_package", "If set, the package this mock will be part of.").String()
+		gomockAuxFiles    = gomockCmd.Flag("aux_files", "Comma-separated pkg=path.go pairs of additional files the source depends on.").String()
+		gomockMockNames   = gomockCmd.Flag("mock_names", "Comma-separated Interface=MockName pairs overriding the generated mock's name.").String()
+		gomockCopyright   = gomockCmd.Flag("copyright_file", "File whose contents are prepended as a header comment to the generated file.").String()
+		gomockImports     = gomockCmd.Flag("imports", "Comma-separated name=path pairs; each imports path under name, replacing the path computed for that name. Types in the generated code stay qualified by their own package's name.").String()
+		gomockSourceRoot  = gomockCmd.Flag("source-root", "If set, strip this prefix from --source in the generated output's header, "+
+			"so it's reproducible across working directories (e.g. in a Bazel sandbox).").String()
 
 		removeMocks          = app.Command("remove", "Remove mocks generated by Pegomock")
 		removeRecursive      = removeMocks.Flag("recursive", "Remove recursively in all sub-directories").Default("false").Short('r').Bool()
@@ -79,12 +121,21 @@ func Run(cliArgs []string, out io.Writer, in io.Reader, app *kingpin.Application
 	switch kingpin.MustParse(app.Parse(cliArgs[1:])) {
 
 	case generateCmd.FullCommand():
-		if err := util.ValidateArgs(*generateCmdArgs); err != nil {
-			app.FatalUsage(err.Error())
-		}
-		sourceArgs, err := util.SourceArgs(*generateCmdArgs)
-		if err != nil {
-			app.FatalUsage(err.Error())
+		var sourceArgs []string
+		var fromModelPkg *model.Package
+		if *fromModel != "" {
+			data, err := os.ReadFile(*fromModel)
+			app.FatalIfError(err, "--from-model")
+			fromModelPkg = &model.Package{}
+			app.FatalIfError(json.Unmarshal(data, fromModelPkg), "--from-model")
+		} else {
+			if err := util.ValidateArgs(*generateCmdArgs); err != nil {
+				app.FatalUsage(err.Error())
+			}
+			sourceArgs, err = util.SourceArgs(*generateCmdArgs)
+			if err != nil {
+				app.FatalUsage(err.Error())
+			}
 		}
 
 		if *destination != "" && *destinationDir != "" {
@@ -104,14 +155,28 @@ func Run(cliArgs []string, out io.Writer, in io.Reader, app *kingpin.Application
 			if *packageOut == "" {
 				realPackageOut = filepath.Base(*destinationDir)
 			}
-			if util.SourceMode(sourceArgs) {
-				realDestination = filepath.Join(*destinationDir, "mock_"+strings.TrimSuffix(sourceArgs[0], ".go")+".go")
-			} else {
-				realDestination = filepath.Join(*destinationDir, "mock_"+strings.ToLower(sourceArgs[len(sourceArgs)-1])+".go")
+		}
+
+		// When --output isn't given, fall back to mock_<interface>.go in
+		// realDestinationDir, the same as when --output-dir is set, except
+		// it's suffixed _test.go when realDestinationDir is just workingDir,
+		// matching the --output flag's documented default.
+		if realDestination == "" {
+			suffix := ".go"
+			if *destinationDir == "" {
+				suffix = "_test.go"
+			}
+			switch {
+			case fromModelPkg != nil:
+				realDestination = filepath.Join(realDestinationDir, "mock_"+strings.ToLower(lastInterfaceName(fromModelPkg))+suffix)
+			case util.SourceMode(sourceArgs):
+				realDestination = filepath.Join(realDestinationDir, "mock_"+strings.TrimSuffix(sourceArgs[0], ".go")+suffix)
+			default:
+				realDestination = filepath.Join(realDestinationDir, "mock_"+strings.ToLower(sourceArgs[len(sourceArgs)-1])+suffix)
 			}
 		}
 
-		filehandling.GenerateMockFileInOutputDir(
+		app.FatalIfError(filehandling.GenerateMockFileInOutputDir(
 			sourceArgs,
 			realDestinationDir,
 			realDestination,
@@ -119,9 +184,39 @@ func Run(cliArgs []string, out io.Writer, in io.Reader, app *kingpin.Application
 			*selfPackage,
 			*debugParser,
 			out,
-			*useExperimentalModelGen,
+			mockgen.Backend(*parser),
 			*shouldGenerateMatchers,
-			*matchersDestination)
+			*matchersDestination,
+			*sourceRoot,
+			fromModelPkg), "")
+
+	case modelCmd.FullCommand():
+		sourceArgs, err := util.SourceArgs(*modelCmdArgs)
+		if err != nil {
+			app.FatalUsage(err.Error())
+		}
+		filehandling.PrintModel(sourceArgs, stdout, out, mockgen.Backend(*modelParser), *modelSourceRoot)
+
+	case gomockCmd.FullCommand():
+		auxFiles, err := util.ParsePkgEqualsPathList(*gomockAuxFiles)
+		app.FatalIfError(err, "--aux_files")
+		mockNames, err := util.ParseEqualsList(*gomockMockNames)
+		app.FatalIfError(err, "--mock_names")
+		imports, err := util.ParseEqualsList(*gomockImports)
+		app.FatalIfError(err, "--imports")
+
+		filehandling.GenerateGomockCompatibleMockFile(filehandling.GomockOptions{
+			Source:        *gomockSource,
+			Interfaces:    util.SplitAndTrim(*gomockInterfaces, ","),
+			Destination:   *gomockDestination,
+			PackageOut:    *gomockPackageOut,
+			SelfPackage:   *gomockSelfPackage,
+			AuxFiles:      auxFiles,
+			MockNames:     mockNames,
+			CopyrightFile: *gomockCopyright,
+			Imports:       imports,
+			SourceRoot:    *gomockSourceRoot,
+		}, stdout, out)
 
 	case watchCmd.FullCommand():
 		var targetPaths []string
@@ -131,7 +226,20 @@ func Run(cliArgs []string, out io.Writer, in io.Reader, app *kingpin.Application
 			targetPaths = *watchPackages
 		}
 		watch.CreateWellKnownInterfaceListFilesIfNecessary(targetPaths)
-		util.Ticker(watch.NewMockFileUpdater(targetPaths, *watchRecursive).Update, 2*time.Second, done)
+
+		if *watchPoll {
+			util.Ticker(watch.NewMockFileUpdater(targetPaths, *watchRecursive).Update, 2*time.Second, done)
+			break
+		}
+
+		socketPath := ""
+		if *watchDaemon {
+			socketPath = *watchSocket
+			if socketPath == "" {
+				socketPath = filepath.Join(workingDir, "pegomock.sock")
+			}
+		}
+		app.FatalIfError(watch.NewDaemon(targetPaths, *watchRecursive, socketPath).Run(targetPaths, done), "")
 
 	case removeMocks.FullCommand():
 		path := *removePath
@@ -143,3 +251,14 @@ func Run(cliArgs []string, out io.Writer, in io.Reader, app *kingpin.Application
 		remove.Remove(path, *removeRecursive, !*removeNonInteractive, *removeDryRun, *removeSilent, out, in, os.Remove)
 	}
 }
+
+// lastInterfaceName returns the name of the last interface in pkg, the
+// same one used to name the output file when generating from parsed
+// args, so --from-model without --output/--output-dir picks a sensible
+// default destination too.
+func lastInterfaceName(pkg *model.Package) string {
+	if len(pkg.Interfaces) == 0 {
+		return pkg.Name
+	}
+	return pkg.Interfaces[len(pkg.Interfaces)-1].Name
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mockgen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/petergtz/pegomock/pegomock/mockgen/model"
+)
+
+// TestGenerateMatcherFilesCreatesDestinationDir guards against a regression
+// where GenerateMatcherFiles assumed its destination directory already
+// existed, so generating matchers for a generic interface into a fresh
+// "matchers" subdirectory (the default --matchers-dir) failed with
+// "no such file or directory" instead of writing matcher_anyt.go.
+func TestGenerateMatcherFilesCreatesDestinationDir(t *testing.T) {
+	pkg := &model.Package{
+		Name: "foo",
+		Interfaces: []*model.Interface{{
+			Name:       "Repo",
+			TypeParams: []*model.TypeParam{{Name: "T", Constraint: model.PredeclaredType("any")}},
+		}},
+	}
+
+	destinationDir := filepath.Join(t.TempDir(), "matchers")
+	if err := GenerateMatcherFiles(pkg, destinationDir); err != nil {
+		t.Fatalf("GenerateMatcherFiles: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destinationDir, "matcher_anyt.go")); err != nil {
+		t.Fatalf("matcher_anyt.go was not written: %v", err)
+	}
+}
+
+// TestGenerateMatcherFilesNoopForNonGenericInterface documents the current
+// (limited) scope of --generate-matchers: it only emits the shared AnyT[T]
+// matcher for generic interfaces, and does nothing -- not even creating
+// destinationDir -- for ordinary interfaces, regardless of how many
+// non-built-in types their methods reference.
+func TestGenerateMatcherFilesNoopForNonGenericInterface(t *testing.T) {
+	pkg := &model.Package{
+		Name: "foo",
+		Interfaces: []*model.Interface{{
+			Name: "Clock",
+			Methods: []*model.Method{
+				{Name: "Now", Out: []*model.Param{{Type: &model.NamedType{Package: "time", PkgPath: "time", Type: "Time"}}}},
+			},
+		}},
+	}
+
+	destinationDir := filepath.Join(t.TempDir(), "matchers")
+	if err := GenerateMatcherFiles(pkg, destinationDir); err != nil {
+		t.Fatalf("GenerateMatcherFiles: %v", err)
+	}
+	if _, err := os.Stat(destinationDir); !os.IsNotExist(err) {
+		t.Fatalf("expected %s not to be created, stat returned: %v", destinationDir, err)
+	}
+}
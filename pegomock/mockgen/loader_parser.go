@@ -0,0 +1,42 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mockgen
+
+import (
+	"fmt"
+
+	"github.com/petergtz/pegomock/pegomock/mockgen/model"
+)
+
+// loaderParser is the original --use-experimental-model-gen backend, built
+// on golang.org/x/tools/go/loader. It is superseded by packagesParser; the
+// loader-based generator isn't part of this build, so --parser=loader
+// reports itself as unsupported instead of calling into a generator that
+// doesn't exist. It will be removed in a future release.
+type loaderParser struct{}
+
+// NewLoaderParser returns the deprecated loader-based Parser.
+func NewLoaderParser() Parser { return &loaderParser{} }
+
+func (p *loaderParser) ParseInterfaces(packagePath string, interfaceNames []string) (*model.Package, error) {
+	if len(interfaceNames) != 1 {
+		return nil, fmt.Errorf("--parser=loader only ever supported a single interface per invocation; use --parser=packages instead")
+	}
+	return nil, fmt.Errorf("--parser=loader is not implemented in this build; use --parser=packages")
+}
+
+func (p *loaderParser) ParseFile(sourceFile string, interfaceNames []string) (*model.Package, error) {
+	return nil, fmt.Errorf("--parser=loader cannot parse a .go source file %q; use --parser=packages", sourceFile)
+}
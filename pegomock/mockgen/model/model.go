@@ -0,0 +1,270 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package model describes the subset of Go's type system that pegomock's
+// code generator understands. It is produced by the packages-based parser
+// in pegomock/mockgen (or read back from JSON) and consumed by the
+// template that renders the generated mock.
+package model
+
+import "fmt"
+
+// Package is a Go package. It may be fed into the model generator to
+// produce mocks for some subset of its interfaces. It's also what the
+// "model" command marshals to JSON and what generate's --from-model reads
+// back, so a Package round-trips through JSON without losing information.
+type Package struct {
+	Name    string `json:"name"`
+	PkgPath string `json:"pkgPath"`
+	// SourcePath is the parser's source argument (a package path or a .go
+	// file), recorded so the generated file can reference where it came
+	// from. Parsers should keep it as given on the command line rather
+	// than resolving it to an absolute path, so that output generated
+	// from the same source is byte-identical regardless of the working
+	// directory it was generated from; see util.RelativeSourcePath.
+	SourcePath string `json:"sourcePath,omitempty"`
+	// Imports maps the short package name codegen renders (e.g. "time")
+	// to its import path, for every external type referenced anywhere in
+	// Interfaces. See ComputeImports.
+	Imports    map[string]string `json:"imports,omitempty"`
+	Interfaces []*Interface      `json:"interfaces"`
+}
+
+// Interface is a Go interface, possibly parameterized by one or more type
+// parameters.
+type Interface struct {
+	Name    string    `json:"name"`
+	Methods []*Method `json:"methods"`
+	// MockName, if non-empty, overrides the generated mock struct's name
+	// (as set by gomock's --mock_names); see MockTypeName.
+	MockName   string       `json:"mockName,omitempty"`
+	TypeParams []*TypeParam `json:"typeParams,omitempty"`
+}
+
+// Method is a single method of an Interface.
+type Method struct {
+	Name     string   `json:"name"`
+	In       []*Param `json:"in,omitempty"`
+	Out      []*Param `json:"out,omitempty"`
+	Variadic *Param   `json:"variadic,omitempty"` // may be nil
+}
+
+// Param is an argument or return value of a Method.
+type Param struct {
+	Name string
+	Type Type
+}
+
+// TypeParam is a single type parameter of a generic Interface, e.g. the
+// `T any` in `type Repo[T any] interface { ... }`.
+type TypeParam struct {
+	Name       string
+	Constraint Type
+}
+
+// Decl renders the interface's type parameter list as it appears in a type
+// declaration, e.g. "[T any, K comparable]", or "" if the interface isn't
+// generic.
+func (iface *Interface) Decl() string {
+	if len(iface.TypeParams) == 0 {
+		return ""
+	}
+	s := "["
+	for i, tp := range iface.TypeParams {
+		if i > 0 {
+			s += ", "
+		}
+		s += tp.Name + " " + tp.Constraint.String()
+	}
+	return s + "]"
+}
+
+// MockTypeName is the name codegen gives the generated mock struct: Name
+// prefixed with "Mock", unless MockName overrides it.
+func (iface *Interface) MockTypeName() string {
+	if iface.MockName != "" {
+		return iface.MockName
+	}
+	return "Mock" + iface.Name
+}
+
+// Instantiation renders the interface's type parameter list as it appears
+// when the type is used, e.g. "[T, K]", or "" if the interface isn't
+// generic.
+func (iface *Interface) Instantiation() string {
+	if len(iface.TypeParams) == 0 {
+		return ""
+	}
+	s := "["
+	for i, tp := range iface.TypeParams {
+		if i > 0 {
+			s += ", "
+		}
+		s += tp.Name
+	}
+	return s + "]"
+}
+
+func (p *Param) String() string {
+	if p.Name != "" {
+		return fmt.Sprintf("%v %v", p.Name, p.Type)
+	}
+	return p.Type.String()
+}
+
+// Type is any type that can appear as a method parameter, result, or field.
+type Type interface {
+	fmt.Stringer
+}
+
+// PredeclaredType is a predeclared type such as "int" or "string".
+type PredeclaredType string
+
+func (t PredeclaredType) String() string { return string(t) }
+
+// NamedType is an exported type in a package, possibly instantiated with
+// type arguments (e.g. a generic type used as a field or parameter type).
+type NamedType struct {
+	Package string // may be empty
+	PkgPath string // import path for Package; may be empty if the parser couldn't resolve it. Equals the enclosing Package.PkgPath for types declared in the package being mocked itself
+	Type    string
+	Args    []Type // type arguments, if Type is generic
+}
+
+func (t *NamedType) String() string {
+	s := t.Type
+	if t.Package != "" {
+		s = t.Package + "." + s
+	}
+	if len(t.Args) == 0 {
+		return s
+	}
+	s += "["
+	for i, a := range t.Args {
+		if i > 0 {
+			s += ", "
+		}
+		s += a.String()
+	}
+	return s + "]"
+}
+
+// PointerType is a pointer to another type.
+type PointerType struct{ Type Type }
+
+func (t *PointerType) String() string { return "*" + t.Type.String() }
+
+// ArrayType is an array or slice type.
+type ArrayType struct {
+	Len  int // -1 for a slice
+	Type Type
+}
+
+func (t *ArrayType) String() string {
+	if t.Len == -1 {
+		return "[]" + t.Type.String()
+	}
+	return fmt.Sprintf("[%d]%s", t.Len, t.Type)
+}
+
+// MapType is a map type.
+type MapType struct{ Key, Value Type }
+
+func (t *MapType) String() string { return fmt.Sprintf("map[%v]%v", t.Key, t.Value) }
+
+// ChanType is a channel type.
+type ChanType struct {
+	Dir  int // one of reflect.ChanDir's values
+	Type Type
+}
+
+func (t *ChanType) String() string { return "chan " + t.Type.String() }
+
+// FuncType is a function type.
+type FuncType struct {
+	In, Out  []*Param
+	Variadic *Param
+}
+
+func (t *FuncType) String() string { return "func(...)" }
+
+// ComputeImports walks every type referenced by pkg's interfaces and
+// returns, keyed by the short package name codegen renders (e.g. "time"),
+// the import path recorded for it by the parser, excluding pkg's own
+// package. It returns nil if Interfaces reference no external types.
+func ComputeImports(pkg *Package) map[string]string {
+	return computeImports(pkg, pkg.PkgPath)
+}
+
+// ComputeImportsExcluding is like ComputeImports, but excludes excludePath
+// instead of pkg's own package. Codegen uses this with the output package
+// (selfPackage) rather than pkg.PkgPath, since a generated mock usually
+// lives in a different package than the interfaces it mocks, and in that
+// case does need to import pkg's own package for any local types it
+// references.
+func ComputeImportsExcluding(pkg *Package, excludePath string) map[string]string {
+	return computeImports(pkg, excludePath)
+}
+
+func computeImports(pkg *Package, exclude string) map[string]string {
+	imports := map[string]string{}
+	var walkType func(Type)
+	walkParams := func(params []*Param) {
+		for _, p := range params {
+			walkType(p.Type)
+		}
+	}
+	walkType = func(t Type) {
+		switch t := t.(type) {
+		case *NamedType:
+			if t.Package != "" && t.PkgPath != "" && t.PkgPath != exclude {
+				imports[t.Package] = t.PkgPath
+			}
+			for _, arg := range t.Args {
+				walkType(arg)
+			}
+		case *PointerType:
+			walkType(t.Type)
+		case *ArrayType:
+			walkType(t.Type)
+		case *MapType:
+			walkType(t.Key)
+			walkType(t.Value)
+		case *ChanType:
+			walkType(t.Type)
+		case *FuncType:
+			walkParams(t.In)
+			walkParams(t.Out)
+			if t.Variadic != nil {
+				walkType(t.Variadic.Type)
+			}
+		}
+	}
+	for _, iface := range pkg.Interfaces {
+		for _, tp := range iface.TypeParams {
+			walkType(tp.Constraint)
+		}
+		for _, m := range iface.Methods {
+			walkParams(m.In)
+			walkParams(m.Out)
+			if m.Variadic != nil {
+				walkType(m.Variadic.Type)
+			}
+		}
+	}
+	if len(imports) == 0 {
+		return nil
+	}
+	return imports
+}
@@ -0,0 +1,288 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Type doesn't marshal to JSON on its own, since encoding/json has no way
+// to recover which concrete implementation a value read back from JSON
+// should become. Param and TypeParam, the two places a Type appears,
+// marshal through typeJSON instead, which tags the value with a "kind"
+// discriminator.
+
+// typeJSON is the JSON form of a Type. Which of its fields are populated
+// depends on Kind; see marshalType and unmarshalType.
+type typeJSON struct {
+	Kind string `json:"kind"`
+
+	Name string `json:"name,omitempty"` // predeclared, named
+
+	Package string      `json:"package,omitempty"` // named
+	PkgPath string      `json:"pkgPath,omitempty"` // named
+	Args    []*typeJSON `json:"args,omitempty"`    // named
+
+	Elem *typeJSON `json:"elem,omitempty"` // pointer, array, map (value), chan
+	Key  *typeJSON `json:"key,omitempty"`  // map
+	Len  int       `json:"len,omitempty"`  // array; -1 for a slice
+
+	Dir int `json:"dir,omitempty"` // chan
+
+	In       []*paramJSON `json:"in,omitempty"`  // func
+	Out      []*paramJSON `json:"out,omitempty"` // func
+	Variadic *paramJSON   `json:"variadic,omitempty"`
+}
+
+type paramJSON struct {
+	Name string    `json:"name,omitempty"`
+	Type *typeJSON `json:"type"`
+}
+
+// MarshalJSON implements json.Marshaler so Param's Type field round-trips
+// through its concrete implementation instead of being flattened away.
+func (p *Param) MarshalJSON() ([]byte, error) {
+	pj, err := paramToJSON(p)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(pj)
+}
+
+// UnmarshalJSON is MarshalJSON's counterpart.
+func (p *Param) UnmarshalJSON(data []byte) error {
+	var pj paramJSON
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return err
+	}
+	param, err := paramFromJSON(&pj)
+	if err != nil {
+		return err
+	}
+	*p = *param
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler so TypeParam's Constraint field
+// round-trips through its concrete implementation instead of being
+// flattened away.
+func (tp *TypeParam) MarshalJSON() ([]byte, error) {
+	constraint, err := marshalType(tp.Constraint)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Name       string    `json:"name"`
+		Constraint *typeJSON `json:"constraint"`
+	}{Name: tp.Name, Constraint: constraint})
+}
+
+// UnmarshalJSON is MarshalJSON's counterpart.
+func (tp *TypeParam) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name       string    `json:"name"`
+		Constraint *typeJSON `json:"constraint"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	constraint, err := unmarshalType(raw.Constraint)
+	if err != nil {
+		return err
+	}
+	tp.Name, tp.Constraint = raw.Name, constraint
+	return nil
+}
+
+func paramToJSON(p *Param) (*paramJSON, error) {
+	if p == nil {
+		return nil, nil
+	}
+	t, err := marshalType(p.Type)
+	if err != nil {
+		return nil, err
+	}
+	return &paramJSON{Name: p.Name, Type: t}, nil
+}
+
+func paramFromJSON(pj *paramJSON) (*Param, error) {
+	if pj == nil {
+		return nil, nil
+	}
+	t, err := unmarshalType(pj.Type)
+	if err != nil {
+		return nil, err
+	}
+	return &Param{Name: pj.Name, Type: t}, nil
+}
+
+func paramsToJSON(params []*Param) ([]*paramJSON, error) {
+	if params == nil {
+		return nil, nil
+	}
+	result := make([]*paramJSON, len(params))
+	for i, p := range params {
+		pj, err := paramToJSON(p)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = pj
+	}
+	return result, nil
+}
+
+func paramsFromJSON(params []*paramJSON) ([]*Param, error) {
+	if params == nil {
+		return nil, nil
+	}
+	result := make([]*Param, len(params))
+	for i, pj := range params {
+		p, err := paramFromJSON(pj)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = p
+	}
+	return result, nil
+}
+
+func marshalType(t Type) (*typeJSON, error) {
+	if t == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case PredeclaredType:
+		return &typeJSON{Kind: "predeclared", Name: string(t)}, nil
+	case *NamedType:
+		tj := &typeJSON{Kind: "named", Name: t.Type, Package: t.Package, PkgPath: t.PkgPath}
+		for _, arg := range t.Args {
+			argJSON, err := marshalType(arg)
+			if err != nil {
+				return nil, err
+			}
+			tj.Args = append(tj.Args, argJSON)
+		}
+		return tj, nil
+	case *PointerType:
+		elem, err := marshalType(t.Type)
+		if err != nil {
+			return nil, err
+		}
+		return &typeJSON{Kind: "pointer", Elem: elem}, nil
+	case *ArrayType:
+		elem, err := marshalType(t.Type)
+		if err != nil {
+			return nil, err
+		}
+		return &typeJSON{Kind: "array", Len: t.Len, Elem: elem}, nil
+	case *MapType:
+		key, err := marshalType(t.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalType(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &typeJSON{Kind: "map", Key: key, Elem: value}, nil
+	case *ChanType:
+		elem, err := marshalType(t.Type)
+		if err != nil {
+			return nil, err
+		}
+		return &typeJSON{Kind: "chan", Dir: t.Dir, Elem: elem}, nil
+	case *FuncType:
+		in, err := paramsToJSON(t.In)
+		if err != nil {
+			return nil, err
+		}
+		out, err := paramsToJSON(t.Out)
+		if err != nil {
+			return nil, err
+		}
+		variadic, err := paramToJSON(t.Variadic)
+		if err != nil {
+			return nil, err
+		}
+		return &typeJSON{Kind: "func", In: in, Out: out, Variadic: variadic}, nil
+	default:
+		return nil, fmt.Errorf("model: marshaling type %T to JSON is not supported", t)
+	}
+}
+
+func unmarshalType(tj *typeJSON) (Type, error) {
+	if tj == nil {
+		return nil, nil
+	}
+	switch tj.Kind {
+	case "predeclared":
+		return PredeclaredType(tj.Name), nil
+	case "named":
+		nt := &NamedType{Package: tj.Package, PkgPath: tj.PkgPath, Type: tj.Name}
+		for _, argJSON := range tj.Args {
+			arg, err := unmarshalType(argJSON)
+			if err != nil {
+				return nil, err
+			}
+			nt.Args = append(nt.Args, arg)
+		}
+		return nt, nil
+	case "pointer":
+		elem, err := unmarshalType(tj.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &PointerType{Type: elem}, nil
+	case "array":
+		elem, err := unmarshalType(tj.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &ArrayType{Len: tj.Len, Type: elem}, nil
+	case "map":
+		key, err := unmarshalType(tj.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := unmarshalType(tj.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &MapType{Key: key, Value: value}, nil
+	case "chan":
+		elem, err := unmarshalType(tj.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &ChanType{Dir: tj.Dir, Type: elem}, nil
+	case "func":
+		in, err := paramsFromJSON(tj.In)
+		if err != nil {
+			return nil, err
+		}
+		out, err := paramsFromJSON(tj.Out)
+		if err != nil {
+			return nil, err
+		}
+		variadic, err := paramFromJSON(tj.Variadic)
+		if err != nil {
+			return nil, err
+		}
+		return &FuncType{In: in, Out: out, Variadic: variadic}, nil
+	default:
+		return nil, fmt.Errorf("model: unknown type kind %q in JSON", tj.Kind)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestInterfaceDeclAndInstantiationNonGeneric(t *testing.T) {
+	iface := &Interface{Name: "Repo"}
+	if got := iface.Decl(); got != "" {
+		t.Errorf("Decl() = %q, want empty", got)
+	}
+	if got := iface.Instantiation(); got != "" {
+		t.Errorf("Instantiation() = %q, want empty", got)
+	}
+}
+
+func TestInterfaceDeclSingleTypeParam(t *testing.T) {
+	iface := &Interface{
+		Name:       "Repo",
+		TypeParams: []*TypeParam{{Name: "T", Constraint: PredeclaredType("any")}},
+	}
+	if got, want := iface.Decl(), "[T any]"; got != want {
+		t.Errorf("Decl() = %q, want %q", got, want)
+	}
+	if got, want := iface.Instantiation(), "[T]"; got != want {
+		t.Errorf("Instantiation() = %q, want %q", got, want)
+	}
+}
+
+func TestInterfaceDeclMultipleTypeParamsWithConstraintTypeSet(t *testing.T) {
+	iface := &Interface{
+		Name: "Repo",
+		TypeParams: []*TypeParam{
+			{Name: "T", Constraint: PredeclaredType("any")},
+			{Name: "K", Constraint: PredeclaredType("comparable")},
+			{Name: "N", Constraint: PredeclaredType("~int | ~float64")},
+		},
+	}
+	if got, want := iface.Decl(), "[T any, K comparable, N ~int | ~float64]"; got != want {
+		t.Errorf("Decl() = %q, want %q", got, want)
+	}
+	if got, want := iface.Instantiation(), "[T, K, N]"; got != want {
+		t.Errorf("Instantiation() = %q, want %q", got, want)
+	}
+}
+
+func TestMethodReturningTypeParam(t *testing.T) {
+	method := &Method{
+		Name: "Get",
+		In:   []*Param{{Name: "id", Type: PredeclaredType("string")}},
+		Out:  []*Param{{Type: PredeclaredType("T")}, {Type: PredeclaredType("error")}},
+	}
+	if len(method.Out) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(method.Out))
+	}
+	if got, want := method.Out[0].Type.String(), "T"; got != want {
+		t.Errorf("first result type = %q, want %q", got, want)
+	}
+}
+
+func TestPackageJSONRoundTrip(t *testing.T) {
+	pkg := &Package{
+		Name:       "foo",
+		PkgPath:    "example.com/foo",
+		SourcePath: "foo.go",
+		Interfaces: []*Interface{{
+			Name:       "Repo",
+			TypeParams: []*TypeParam{{Name: "T", Constraint: PredeclaredType("any")}},
+			Methods: []*Method{{
+				Name: "Get",
+				In:   []*Param{{Name: "id", Type: PredeclaredType("string")}},
+				Out: []*Param{
+					{Type: &PointerType{Type: &NamedType{Package: "time", PkgPath: "time", Type: "Time"}}},
+					{Type: &ArrayType{Len: -1, Type: PredeclaredType("byte")}},
+					{Type: PredeclaredType("error")},
+				},
+				Variadic: &Param{Name: "opts", Type: &NamedType{Package: "foo", Type: "Option"}},
+			}},
+		}},
+	}
+	pkg.Imports = ComputeImports(pkg)
+
+	data, err := json.Marshal(pkg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var got Package
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(pkg, &got) {
+		t.Fatalf("round trip through JSON lost information:\nwant: %#v\ngot:  %#v", pkg, &got)
+	}
+}
+
+func TestComputeImportsIgnoresLocalAndPredeclaredTypes(t *testing.T) {
+	pkg := &Package{
+		PkgPath: "example.com/foo",
+		Interfaces: []*Interface{{
+			Name: "Repo",
+			Methods: []*Method{{
+				Name: "Get",
+				Out: []*Param{
+					{Type: &NamedType{Package: "time", PkgPath: "time", Type: "Time"}},
+					// Same package as pkg itself, as the parser records it: PkgPath set, but equal to pkg.PkgPath.
+					{Type: &NamedType{Package: "foo", PkgPath: "example.com/foo", Type: "LocalType"}},
+					{Type: PredeclaredType("error")},
+				},
+			}},
+		}},
+	}
+	imports := ComputeImports(pkg)
+	if want := map[string]string{"time": "time"}; !reflect.DeepEqual(imports, want) {
+		t.Errorf("ComputeImports() = %v, want %v", imports, want)
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mockgen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/petergtz/pegomock/pegomock/mockgen/model"
+)
+
+const packagesLoadMode = packages.NeedName | packages.NeedTypes | packages.NeedSyntax |
+	packages.NeedTypesInfo | packages.NeedDeps | packages.NeedImports
+
+// packagesParser builds a model.Package using golang.org/x/tools/go/packages.
+// Unlike the reflect-based parser it can read .go source files directly and
+// understands Go modules, build tags and CGO; unlike the old loader-based
+// parser it isn't limited to a single interface per invocation.
+type packagesParser struct{}
+
+// NewPackagesParser returns a Parser that resolves interfaces via
+// golang.org/x/tools/go/packages.
+func NewPackagesParser() Parser { return &packagesParser{} }
+
+func (p *packagesParser) ParseInterfaces(packagePath string, interfaceNames []string) (*model.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: packagesLoadMode}, packagePath)
+	if err != nil {
+		return nil, fmt.Errorf("loading package %q: %w", packagePath, err)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("package %q not found", packagePath)
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return nil, fmt.Errorf("parsing package %q: %v", packagePath, pkg.Errors[0])
+	}
+	return p.modelFromPackage(pkg, interfaceNames, packagePath)
+}
+
+func (p *packagesParser) ParseFile(sourceFile string, interfaceNames []string) (*model.Package, error) {
+	cfg := &packages.Config{Mode: packagesLoadMode, Tests: false}
+	pkgs, err := packages.Load(cfg, "file="+sourceFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading %q: %w", sourceFile, err)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("file %q not found in any package", sourceFile)
+	}
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		return nil, fmt.Errorf("parsing %q: %v", sourceFile, pkg.Errors[0])
+	}
+	return p.modelFromPackage(pkg, interfaceNames, sourceFile)
+}
+
+// modelFromPackage walks the resolved *types.Package for every interface
+// type, exported or not (or just interfaceNames, if non-empty), and converts it to
+// the model the template renders from. Parameter names are recovered from
+// the AST, which reflection cannot do. sourceArg is recorded verbatim as
+// model.Package.SourcePath; callers rewrite it relative to --source-root
+// before it's embedded in generated output.
+func (p *packagesParser) modelFromPackage(pkg *packages.Package, interfaceNames []string, sourceArg string) (*model.Package, error) {
+	wanted := make(map[string]bool, len(interfaceNames))
+	for _, name := range interfaceNames {
+		wanted[name] = true
+	}
+
+	result := &model.Package{Name: pkg.Name, PkgPath: pkg.PkgPath, SourcePath: sourceArg}
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() { // Names() is sorted, so interface order is deterministic
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		iface, ok := obj.Type().Underlying().(*types.Interface)
+		if !ok {
+			continue
+		}
+		mi, err := p.interfaceFromTypes(name, obj, iface, astParamNames(pkg.Syntax, name))
+		if err != nil {
+			return nil, fmt.Errorf("interface %s: %w", name, err)
+		}
+		result.Interfaces = append(result.Interfaces, mi)
+	}
+	if len(wanted) > 0 && len(result.Interfaces) != len(wanted) {
+		return nil, fmt.Errorf("not all requested interfaces were found in package %q", pkg.PkgPath)
+	}
+	return result, nil
+}
+
+func (p *packagesParser) interfaceFromTypes(name string, obj *types.TypeName, iface *types.Interface, paramNames map[string][]string) (*model.Interface, error) {
+	mi := &model.Interface{Name: name}
+
+	if named, ok := obj.Type().(*types.Named); ok {
+		for i := 0; i < named.TypeParams().Len(); i++ {
+			tp := named.TypeParams().At(i)
+			mi.TypeParams = append(mi.TypeParams, &model.TypeParam{
+				Name:       tp.Obj().Name(),
+				Constraint: p.typeToModel(tp.Constraint()),
+			})
+		}
+	}
+
+	iface = iface.Complete()
+	for i := 0; i < iface.NumMethods(); i++ {
+		fn := iface.Method(i)
+		sig := fn.Type().(*types.Signature)
+		names := paramNames[fn.Name()]
+		method := &model.Method{Name: fn.Name()}
+		for j := 0; j < sig.Params().Len(); j++ {
+			v := sig.Params().At(j)
+			method.In = append(method.In, &model.Param{
+				Name: paramName(names, j, v.Name()),
+				Type: p.typeToModel(v.Type()),
+			})
+		}
+		if sig.Variadic() && len(method.In) > 0 {
+			last := method.In[len(method.In)-1]
+			last.Type = last.Type.(*model.ArrayType).Type
+			method.Variadic = last
+			method.In = method.In[:len(method.In)-1]
+		}
+		for j := 0; j < sig.Results().Len(); j++ {
+			v := sig.Results().At(j)
+			method.Out = append(method.Out, &model.Param{Name: v.Name(), Type: p.typeToModel(v.Type())})
+		}
+		mi.Methods = append(mi.Methods, method)
+	}
+	return mi, nil
+}
+
+func paramName(names []string, index int, fallback string) string {
+	if index < len(names) && names[index] != "" {
+		return names[index]
+	}
+	return fallback
+}
+
+// astParamNames recovers the real argument names of every method of the
+// named interface declared in files, keyed by method name. Plain reflection
+// only ever sees "argN" because the compiled binary doesn't retain them.
+func astParamNames(files []*ast.File, interfaceName string) map[string][]string {
+	names := map[string][]string{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok || ts.Name.Name != interfaceName {
+					continue
+				}
+				it, ok := ts.Type.(*ast.InterfaceType)
+				if !ok {
+					continue
+				}
+				for _, field := range it.Methods.List {
+					ft, ok := field.Type.(*ast.FuncType)
+					if !ok || len(field.Names) == 0 {
+						continue
+					}
+					names[field.Names[0].Name] = fieldListNames(ft.Params)
+				}
+			}
+		}
+	}
+	return names
+}
+
+func fieldListNames(fields *ast.FieldList) []string {
+	if fields == nil {
+		return nil
+	}
+	var names []string
+	for _, field := range fields.List {
+		if len(field.Names) == 0 {
+			names = append(names, "")
+			continue
+		}
+		for _, n := range field.Names {
+			names = append(names, n.Name)
+		}
+	}
+	return names
+}
+
+func (p *packagesParser) typeToModel(t types.Type) model.Type {
+	switch t := t.(type) {
+	case *types.Basic:
+		return model.PredeclaredType(t.Name())
+	case *types.Pointer:
+		return &model.PointerType{Type: p.typeToModel(t.Elem())}
+	case *types.Slice:
+		return &model.ArrayType{Len: -1, Type: p.typeToModel(t.Elem())}
+	case *types.Array:
+		return &model.ArrayType{Len: int(t.Len()), Type: p.typeToModel(t.Elem())}
+	case *types.Map:
+		return &model.MapType{Key: p.typeToModel(t.Key()), Value: p.typeToModel(t.Elem())}
+	case *types.Chan:
+		dir := reflect.BothDir
+		switch t.Dir() {
+		case types.SendOnly:
+			dir = reflect.SendDir
+		case types.RecvOnly:
+			dir = reflect.RecvDir
+		}
+		return &model.ChanType{Dir: int(dir), Type: p.typeToModel(t.Elem())}
+	case *types.Signature:
+		return p.signatureToModel(t)
+	case *types.Named:
+		return p.namedToModel(t)
+	case *types.TypeParam:
+		return model.PredeclaredType(t.Obj().Name())
+	case *types.Interface:
+		if t.NumMethods() == 0 {
+			return model.PredeclaredType("interface{}")
+		}
+		return model.PredeclaredType("interface{ /* ... */ }")
+	default:
+		return model.PredeclaredType(strings.TrimPrefix(fmt.Sprintf("%v", t), "*"))
+	}
+}
+
+func (p *packagesParser) namedToModel(t *types.Named) model.Type {
+	obj := t.Obj()
+	nt := &model.NamedType{Type: obj.Name()}
+	if pkg := obj.Pkg(); pkg != nil {
+		nt.Package = pkg.Name()
+		nt.PkgPath = pkg.Path()
+	}
+	if args := t.TypeArgs(); args != nil {
+		for i := 0; i < args.Len(); i++ {
+			nt.Args = append(nt.Args, p.typeToModel(args.At(i)))
+		}
+	}
+	return nt
+}
+
+func (p *packagesParser) signatureToModel(sig *types.Signature) model.Type {
+	ft := &model.FuncType{}
+	for i := 0; i < sig.Params().Len(); i++ {
+		ft.In = append(ft.In, &model.Param{Type: p.typeToModel(sig.Params().At(i).Type())})
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		ft.Out = append(ft.Out, &model.Param{Type: p.typeToModel(sig.Results().At(i).Type())})
+	}
+	return ft
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mockgen parses Go packages and .go source files into a
+// model.Package that the mock code generator can render.
+package mockgen
+
+import "github.com/petergtz/pegomock/pegomock/mockgen/model"
+
+// Backend selects which parser is used to build the model.Package for a
+// generate invocation.
+type Backend string
+
+const (
+	// BackendPackages parses source using golang.org/x/tools/go/packages.
+	// It is the default: it understands Go modules, build tags, CGO, and
+	// can parse multiple interfaces from arbitrary .go files in one pass.
+	BackendPackages Backend = "packages"
+	// BackendReflect selects the original reflection-based backend. It is
+	// still accepted as --parser=reflect, but its parser is not implemented
+	// in this build and returns an error for every input.
+	BackendReflect Backend = "reflect"
+	// BackendLoader is the deprecated golang.org/x/tools/go/loader backend.
+	// It is still accepted as --parser=loader, but its parser is not
+	// implemented in this build and returns an error for every input.
+	BackendLoader Backend = "loader"
+)
+
+// Parser builds a model.Package from one of the forms accepted on the
+// pegomock command line: a package path plus one or more interface names,
+// or a .go source file.
+type Parser interface {
+	ParseInterfaces(packagePath string, interfaceNames []string) (*model.Package, error)
+	ParseFile(sourceFile string, interfaceNames []string) (*model.Package, error)
+}
+
+// NewParser returns the Parser implementation for the given backend.
+func NewParser(backend Backend) (Parser, error) {
+	switch backend {
+	case BackendPackages, "":
+		return NewPackagesParser(), nil
+	case BackendReflect:
+		return NewReflectParser(), nil
+	case BackendLoader:
+		return NewLoaderParser(), nil
+	}
+	return nil, &UnknownBackendError{Backend: backend}
+}
+
+// UnknownBackendError is returned by NewParser when asked for a backend it
+// doesn't recognize.
+type UnknownBackendError struct{ Backend Backend }
+
+func (e *UnknownBackendError) Error() string {
+	return "unknown parser backend: " + string(e.Backend)
+}
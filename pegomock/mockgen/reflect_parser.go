@@ -0,0 +1,43 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mockgen
+
+import (
+	"fmt"
+
+	"github.com/petergtz/pegomock/pegomock/mockgen/model"
+)
+
+// reflectParser is the original, default-until-now backend. It used to
+// build and run a small throwaway program that imported the target package
+// and walked its interfaces via reflection; that program generator isn't
+// part of this build, so it reports --parser=reflect as unsupported rather
+// than calling into a generator that doesn't exist. It cannot parse .go
+// source files directly and cannot recover real parameter names.
+type reflectParser struct{}
+
+// NewReflectParser returns the reflection-based Parser.
+func NewReflectParser() Parser { return &reflectParser{} }
+
+func (p *reflectParser) ParseInterfaces(packagePath string, interfaceNames []string) (*model.Package, error) {
+	if len(interfaceNames) != 1 {
+		return nil, fmt.Errorf("the reflect backend only supports a single interface per invocation, got %d", len(interfaceNames))
+	}
+	return nil, fmt.Errorf("--parser=reflect is not implemented in this build; use --parser=packages")
+}
+
+func (p *reflectParser) ParseFile(sourceFile string, interfaceNames []string) (*model.Package, error) {
+	return nil, fmt.Errorf("the reflect backend cannot parse a .go source file %q; use --parser=packages", sourceFile)
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package util contains small helpers shared by pegomock's CLI commands
+// that don't belong to any one of them.
+package util
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ValidateArgs checks the positional "args" passed to the generate command:
+// either a single .go file, or a package path followed by one or more
+// interface names.
+func ValidateArgs(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("requires at least one argument: a .go file, or a package path and interface name(s)")
+	}
+	if !strings.HasSuffix(args[0], ".go") && len(args) < 2 {
+		return fmt.Errorf("requires a package path and at least one interface name")
+	}
+	return nil
+}
+
+// SourceArgs is ValidateArgs' counterpart: it returns args unchanged, having
+// already established they're either [file.go] or [pkgPath, interfaces...].
+func SourceArgs(args []string) ([]string, error) {
+	if err := ValidateArgs(args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// SourceMode reports whether sourceArgs names a .go source file, as opposed
+// to a package path plus interface names.
+func SourceMode(sourceArgs []string) bool {
+	return len(sourceArgs) == 1 && strings.HasSuffix(sourceArgs[0], ".go")
+}
+
+// Ticker calls fn every interval until done receives a value.
+func Ticker(fn func(), interval time.Duration, done chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fn()
+		case <-done:
+			return
+		}
+	}
+}
+
+// SplitAndTrim splits s on sep and trims whitespace from each part,
+// dropping empty parts. It returns nil for an empty s.
+func SplitAndTrim(s string, sep string) []string {
+	if s == "" {
+		return nil
+	}
+	var result []string
+	for _, part := range strings.Split(s, sep) {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// ParseEqualsList parses a comma-separated list of key=value pairs, e.g.
+// "Foo=Bar,Baz=Qux", as used by --mock_names and --imports.
+func ParseEqualsList(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	result := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("malformed key=value pair %q", pair)
+		}
+		result[kv[0]] = kv[1]
+	}
+	return result, nil
+}
+
+// ParsePkgEqualsPathList parses a comma-separated list of pkg=path.go pairs,
+// as used by --aux_files.
+func ParsePkgEqualsPathList(s string) (map[string]string, error) {
+	return ParseEqualsList(s)
+}
+
+// RelativeSourcePath rewrites path relative to root, as used by
+// --source-root to keep any source path embedded in generated output from
+// leaking the absolute filesystem layout of the machine it was generated
+// on. It returns path unchanged if root is empty, path isn't absolute, or
+// path doesn't live under root.
+func RelativeSourcePath(root, path string) string {
+	if root == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
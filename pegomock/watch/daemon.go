@@ -0,0 +1,177 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const debounceWindow = 200 * time.Millisecond
+
+// Daemon watches targetPaths for changes to .go files using fsnotify and
+// regenerates mocks as needed, without the CPU cost and latency of polling.
+// It additionally accepts commands over a Unix domain socket so editors and
+// CI can drive it directly.
+type Daemon struct {
+	updater    *MockFileUpdater
+	recursive  bool
+	socketPath string
+}
+
+// NewDaemon returns a Daemon that watches targetPaths (recursing into
+// sub-directories when recursive is set) and serves commands on socketPath.
+// socketPath may be empty, in which case the command socket is not started.
+func NewDaemon(targetPaths []string, recursive bool, socketPath string) *Daemon {
+	return &Daemon{
+		updater:    NewMockFileUpdater(targetPaths, recursive),
+		recursive:  recursive,
+		socketPath: socketPath,
+	}
+}
+
+// Run watches until done receives a value, regenerating mocks as their
+// source interfaces change. It blocks the calling goroutine.
+func (d *Daemon) Run(targetPaths []string, done chan bool) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating fsnotify watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	for _, path := range targetPaths {
+		if err := d.addRecursive(watcher, path); err != nil {
+			return err
+		}
+	}
+
+	if d.socketPath != "" {
+		listener, err := d.listenCommands()
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+	}
+
+	// pending and the debounce timer are only ever touched from this
+	// goroutine: debounce fires into the same select loop that populates
+	// pending, rather than onto its own goroutine via time.AfterFunc, so
+	// there's no need to guard pending with a mutex.
+	pending := map[string]bool{}
+	debounce := time.NewTimer(debounceWindow)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !strings.HasSuffix(event.Name, ".go") {
+				continue
+			}
+			pending[filepath.Dir(event.Name)] = true
+			if !debounce.Stop() {
+				select {
+				case <-debounce.C:
+				default:
+				}
+			}
+			debounce.Reset(debounceWindow)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, "pegomock watch:", err)
+
+		case <-debounce.C:
+			for path := range pending {
+				d.updater.UpdatePath(path)
+			}
+			pending = map[string]bool{}
+
+		case <-done:
+			return nil
+		}
+	}
+}
+
+func (d *Daemon) addRecursive(watcher *fsnotify.Watcher, root string) error {
+	if !d.recursive {
+		return watcher.Add(root)
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// listenCommands starts serving "regenerate", "list", and "status" commands
+// on d.socketPath, one per connection. Each is answered with a single line,
+// except "list", which writes one line per watched path.
+func (d *Daemon) listenCommands() (net.Listener, error) {
+	os.Remove(d.socketPath)
+	listener, err := net.Listen("unix", d.socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", d.socketPath, err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.handleCommand(conn)
+		}
+	}()
+	return listener, nil
+}
+
+func (d *Daemon) handleCommand(conn net.Conn) {
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return
+	}
+	switch strings.TrimSpace(line) {
+	case "regenerate":
+		d.updater.Update()
+		fmt.Fprintln(conn, "ok")
+	case "list":
+		for _, path := range d.updater.targetPaths {
+			fmt.Fprintln(conn, path)
+		}
+	case "status":
+		fmt.Fprintln(conn, "watching", len(d.updater.targetPaths), "path(s)")
+	default:
+		fmt.Fprintln(conn, "unknown command")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2016 Peter Goetz
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package watch implements the "watch" command: it regenerates mocks
+// whenever the interface files they're generated from change.
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/petergtz/pegomock/pegomock/filehandling"
+	"github.com/petergtz/pegomock/pegomock/mockgen"
+)
+
+// wellKnownInterfaceListFile is pegomock's established convention for the
+// per-directory file watch reads to find out what to (re)generate.
+// deprecatedInterfaceListFile was used briefly for the same purpose while
+// the fsnotify-based daemon was under development; it's still read as a
+// fallback so existing setups don't silently stop regenerating anything,
+// but new list files should use wellKnownInterfaceListFile.
+const (
+	wellKnownInterfaceListFile  = "interfaces_to_mock"
+	deprecatedInterfaceListFile = ".pegomock-interfaces"
+)
+
+// CreateWellKnownInterfaceListFilesIfNecessary ensures each of targetPaths
+// has a well-known file listing the interfaces pegomock should watch for
+// changes, creating an empty one if it doesn't already exist. Each
+// non-empty, non-comment ("#"-prefixed) line is a generate invocation's
+// worth of source args: a Go package path followed by one or more
+// space-separated interface names, e.g. "example.com/foo Reader Writer".
+func CreateWellKnownInterfaceListFilesIfNecessary(targetPaths []string) {
+	for _, path := range targetPaths {
+		listFile := filepath.Join(path, wellKnownInterfaceListFile)
+		if _, err := os.Stat(listFile); os.IsNotExist(err) {
+			os.WriteFile(listFile, nil, 0644)
+		}
+	}
+}
+
+// MockFileUpdater regenerates mocks for the interfaces found under
+// targetPaths (and, if recursive is set, their sub-directories).
+type MockFileUpdater struct {
+	targetPaths []string
+	recursive   bool
+}
+
+// NewMockFileUpdater returns a MockFileUpdater for targetPaths.
+func NewMockFileUpdater(targetPaths []string, recursive bool) *MockFileUpdater {
+	return &MockFileUpdater{targetPaths: targetPaths, recursive: recursive}
+}
+
+// Update regenerates every mock listed in the interface list file of each
+// target path (and, if recursive is set, of each of its sub-directories),
+// whether or not its source interface has changed.
+func (u *MockFileUpdater) Update() {
+	for _, path := range u.targetPaths {
+		if !u.recursive {
+			u.updatePath(path)
+			continue
+		}
+		filepath.Walk(path, func(dir string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() {
+				u.updatePath(dir)
+			}
+			return nil
+		})
+	}
+}
+
+func (u *MockFileUpdater) updatePath(path string) {
+	data, err := os.ReadFile(filepath.Join(path, wellKnownInterfaceListFile))
+	if os.IsNotExist(err) {
+		data, err = os.ReadFile(filepath.Join(path, deprecatedInterfaceListFile))
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "pegomock watch: %s is deprecated, rename it to %s\n",
+				filepath.Join(path, deprecatedInterfaceListFile), wellKnownInterfaceListFile)
+		}
+	}
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sourceArgs := strings.Fields(line)
+		destination := filepath.Join(path, "mock_"+strings.ToLower(sourceArgs[len(sourceArgs)-1])+".go")
+		if err := filehandling.GenerateMockFileInOutputDir(
+			sourceArgs,
+			path,
+			destination,
+			filepath.Base(path),
+			"",
+			false,
+			os.Stderr,
+			mockgen.BackendPackages,
+			false,
+			"",
+			"",
+			nil,
+		); err != nil {
+			// A single interface failing to parse or generate (e.g. a
+			// transient syntax error while the user is mid-edit) must not
+			// bring down a long-running watch/daemon process.
+			fmt.Fprintf(os.Stderr, "pegomock watch: %s: %v\n", line, err)
+		}
+	}
+}
+
+// UpdatePath regenerates the mocks declared under a single directory. It is
+// exported so Daemon can re-run it for just the directory an fsnotify event
+// fired in, instead of the whole watch set.
+func (u *MockFileUpdater) UpdatePath(path string) { u.updatePath(path) }